@@ -0,0 +1,526 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/jmhodges/clock"
+
+	"github.com/letsencrypt/boulder/core"
+	berrors "github.com/letsencrypt/boulder/errors"
+	blog "github.com/letsencrypt/boulder/log"
+	rapb "github.com/letsencrypt/boulder/ra/proto"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// admin holds the dependencies needed to run the revocation subcommands: a
+// read-only SA client for discovering serials, an RA client for revoking
+// them, a direct DB handle for the queries the SA doesn't expose, and a
+// logger. It is constructed once per invocation of `admin revoke-cert`.
+type admin struct {
+	rac    rapb.RegistrationAuthorityClient
+	saroc  sapb.StorageAuthorityReadOnlyClient
+	dbMap  dbSelector
+	log    blog.Logger
+	clk    clock.Clock
+	dryRun bool
+}
+
+// dbSelector is the subset of *db.WrappedMap that the serialsFrom* helpers
+// rely on to stream query results row by row, rather than materializing an
+// entire result set before returning. It exists so tests can swap in a real
+// test DB without the admin struct needing to know about the concrete db
+// package type.
+type dbSelector interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// dryRunRAC is a rapb.RegistrationAuthorityClient which never actually talks
+// to the RA: it just logs what it would have done. It's swapped in for
+// admin.rac whenever admin.dryRun is set.
+type dryRunRAC struct {
+	rapb.RegistrationAuthorityClient
+	log blog.Logger
+}
+
+// AdministrativelyRevokeCertificate logs the revocation that would have been
+// requested, instead of performing it.
+func (d dryRunRAC) AdministrativelyRevokeCertificate(_ context.Context, req *rapb.AdministrativelyRevokeCertificateRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	d.log.Infof("dry-run: would revoke serial %q with reason %d (skipBlockKey=%v, malformed=%v)",
+		req.Serial, req.Code, req.SkipBlockKey, req.Malformed)
+	return &emptypb.Empty{}, nil
+}
+
+// streamSerials runs produce in a goroutine, and returns the channel it
+// sends serials to along with a channel carrying produce's eventual error
+// (nil on success). It's the shared shape behind every serialsFrom*
+// helper below: each one streams its results as it discovers them, rather
+// than materializing the whole list before revokeSerials can start, so an
+// operator revoking millions of serials isn't bottlenecked (or OOM-killed)
+// waiting for the full list to load.
+//
+// Callers should drain the returned channel fully (or cancel ctx) before
+// reading the error channel, since produce won't have reported its error
+// until it's done sending.
+func streamSerials(ctx context.Context, produce func(out chan<- string) error) (<-chan string, <-chan error) {
+	out := make(chan string)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(out)
+		errc <- produce(out)
+	}()
+	return out, errc
+}
+
+// serialsFromIncidentTable streams every serial recorded in the given
+// incident table from the SA, as the SA itself streams them.
+func (a *admin) serialsFromIncidentTable(ctx context.Context, tableName string) (<-chan string, <-chan error) {
+	return streamSerials(ctx, func(out chan<- string) error {
+		stream, err := a.saroc.SerialsForIncident(ctx, &sapb.SerialsForIncidentRequest{IncidentTable: tableName})
+		if err != nil {
+			return fmt.Errorf("requesting serials for incident table %q: %w", tableName, err)
+		}
+
+		for {
+			is, err := stream.Recv()
+			if err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return fmt.Errorf("receiving serial for incident table %q: %w", tableName, err)
+			}
+			select {
+			case out <- is.Serial:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+}
+
+// serialsFromFile streams one serial per (whitespace-trimmed, non-blank)
+// line of the given file, without reading the whole file into memory.
+func (a *admin) serialsFromFile(ctx context.Context, filename string) (<-chan string, <-chan error) {
+	return streamSerials(ctx, func(out chan<- string) error {
+		f, err := os.Open(filename)
+		if err != nil {
+			return fmt.Errorf("opening serials file %q: %w", filename, err)
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			select {
+			case out <- line:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("reading serials file %q: %w", filename, err)
+		}
+		return nil
+	})
+}
+
+// serialsFromPrivateKey streams every unexpired serial whose certificate
+// was issued for the public key matching the given private key.
+func (a *admin) serialsFromPrivateKey(ctx context.Context, keyFile string) (<-chan string, <-chan error) {
+	return streamSerials(ctx, func(out chan<- string) error {
+		keyPEM, err := os.ReadFile(keyFile)
+		if err != nil {
+			return fmt.Errorf("reading private key file %q: %w", keyFile, err)
+		}
+
+		block, _ := pem.Decode(keyPEM)
+		if block == nil {
+			return fmt.Errorf("no PEM block found in %q", keyFile)
+		}
+
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("parsing private key in %q: %w", keyFile, err)
+		}
+
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return fmt.Errorf("key in %q is not a signing key", keyFile)
+		}
+
+		keyHash, err := core.KeyDigest(signer.Public())
+		if err != nil {
+			return fmt.Errorf("computing SPKI hash of %q: %w", keyFile, err)
+		}
+
+		rows, err := a.dbMap.QueryContext(ctx,
+			"SELECT certSerial FROM keyHashToSerial WHERE keyHash = ? AND certNotAfter > ?",
+			keyHash[:], a.clk.Now())
+		if err != nil {
+			return fmt.Errorf("querying keyHashToSerial: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var serial string
+			err := rows.Scan(&serial)
+			if err != nil {
+				return fmt.Errorf("reading keyHashToSerial row: %w", err)
+			}
+			select {
+			case out <- serial:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return rows.Err()
+	})
+}
+
+// serialsFromRegID streams every serial issued to the given registration
+// ID.
+func (a *admin) serialsFromRegID(ctx context.Context, regID int64) (<-chan string, <-chan error) {
+	return streamSerials(ctx, func(out chan<- string) error {
+		_, err := a.saroc.GetRegistration(ctx, &sapb.RegistrationID{Id: regID})
+		if err != nil {
+			return fmt.Errorf("confirming registration ID %d exists: %w", regID, err)
+		}
+
+		rows, err := a.dbMap.QueryContext(ctx, "SELECT serial FROM serials WHERE registrationID = ?", regID)
+		if err != nil {
+			return fmt.Errorf("querying serials: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var serial string
+			err := rows.Scan(&serial)
+			if err != nil {
+				return fmt.Errorf("reading serials row: %w", err)
+			}
+			select {
+			case out <- serial:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return rows.Err()
+	})
+}
+
+// revocationOutcome is the result of attempting to revoke a single serial,
+// as recorded in the JSONL summary file.
+type revocationOutcome string
+
+const (
+	outcomeRevoked        revocationOutcome = "revoked"
+	outcomeAlreadyRevoked revocationOutcome = "already-revoked"
+	outcomeFailed         revocationOutcome = "failed"
+	outcomeSkipped        revocationOutcome = "skipped"
+)
+
+// revocationResult is one line of the JSONL summary file.
+type revocationResult struct {
+	Serial  string            `json:"serial"`
+	Outcome revocationOutcome `json:"outcome"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// revokeCheckpoint is the on-disk representation of revocation progress,
+// written to revokeSerialsConfig.CheckpointPath after every CheckpointEvery
+// processed serials so a
+// restarted run can pick up where the last one left off. LastIndex is the
+// position, in the order serials were read off the input channel, of the
+// last serial in an unbroken run of processed serials from the start of
+// that run; LastSerial is that same serial, kept only so the checkpoint
+// file is human-readable.
+type revokeCheckpoint struct {
+	LastIndex  int64                       `json:"lastIndex"`
+	LastSerial string                      `json:"lastSerial"`
+	Counts     map[revocationOutcome]int64 `json:"counts"`
+}
+
+// loadCheckpoint reads a checkpoint file written by a previous run. A
+// missing file is not an error: it just means there's nothing to resume.
+func loadCheckpoint(path string) (*revokeCheckpoint, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &revokeCheckpoint{LastIndex: -1, Counts: make(map[revocationOutcome]int64)}, nil
+		}
+		return nil, fmt.Errorf("reading checkpoint %q: %w", path, err)
+	}
+
+	var c revokeCheckpoint
+	err = json.Unmarshal(contents, &c)
+	if err != nil {
+		return nil, fmt.Errorf("parsing checkpoint %q: %w", path, err)
+	}
+	if c.Counts == nil {
+		c.Counts = make(map[revocationOutcome]int64)
+	}
+	return &c, nil
+}
+
+// save atomically persists the checkpoint by writing to a temp file and
+// renaming it over the destination, so a crash mid-write can't corrupt the
+// checkpoint a resumed run relies on.
+func (c *revokeCheckpoint) save(path string) error {
+	contents, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshalling checkpoint: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, contents, 0644)
+	if err != nil {
+		return fmt.Errorf("writing checkpoint %q: %w", tmp, err)
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return fmt.Errorf("renaming checkpoint %q to %q: %w", tmp, path, err)
+	}
+	return nil
+}
+
+// revokeSerialsConfig controls the operational knobs of a bulk revocation
+// run: how fast to go, and where to persist progress. The zero value runs
+// unthrottled with no checkpointing or summary output, matching the
+// behavior of a one-off interactive revocation.
+type revokeSerialsConfig struct {
+	// CheckpointPath, if set, is read at startup to skip already-processed
+	// serials, and rewritten after every CheckpointEvery processed serials
+	// (skipped ones included; after every serial if CheckpointEvery <= 0).
+	CheckpointPath  string
+	CheckpointEvery int
+
+	// SummaryPath, if set, receives one JSON object per processed serial.
+	SummaryPath string
+
+	// RatePerSecond, if positive, caps the number of RA revocation calls
+	// per second (with Burst allowed above that rate in short bursts).
+	RatePerSecond float64
+	Burst         int
+}
+
+// indexedSerial tags a serial with its position in the order it was read
+// off the input channel, so concurrent workers can check it against the
+// checkpointed resume point without racing each other over a shared
+// "have we reached it yet" flag.
+type indexedSerial struct {
+	serial string
+	index  int64
+}
+
+// revokeSerials reads serials from the given channel and revokes each of
+// them according to the decision that decide resolves it to, using up to
+// `parallelism` concurrent RA calls. Resolving a decision per serial,
+// rather than taking one reasonCode/skipBlockKey/malformed triple for the
+// whole run, is what lets a single invocation correctly handle a
+// multi-cause bulk revocation (see revocationPolicy). It is built to run
+// unattended over very large (potentially unbounded) serial lists:
+// progress is checkpointed so a restart after an RA or SA hiccup doesn't
+// lose work, an optional token bucket limits the call rate the RA sees,
+// and a structured outcome is written to the summary file for every serial
+// it handles.
+func (a *admin) revokeSerials(ctx context.Context, serials <-chan string, decide func(serial string) revocationDecision, parallelism int, cfg revokeSerialsConfig) error {
+	var checkpoint *revokeCheckpoint
+	var err error
+	if cfg.CheckpointPath != "" {
+		checkpoint, err = loadCheckpoint(cfg.CheckpointPath)
+		if err != nil {
+			return err
+		}
+	} else {
+		checkpoint = &revokeCheckpoint{LastIndex: -1, Counts: make(map[revocationOutcome]int64)}
+	}
+	resumeThreshold := checkpoint.LastIndex
+
+	var summary *os.File
+	if cfg.SummaryPath != "" {
+		summary, err = os.OpenFile(cfg.SummaryPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("opening summary file %q: %w", cfg.SummaryPath, err)
+		}
+		defer summary.Close()
+	}
+
+	var limiter *rate.Limiter
+	if cfg.RatePerSecond > 0 {
+		burst := cfg.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(cfg.RatePerSecond), burst)
+	}
+
+	// dispatched tags every serial with its position in the input order,
+	// in a single goroutine, so that position is well defined regardless
+	// of which of the parallelism workers below ends up handling it.
+	dispatched := make(chan indexedSerial)
+	go func() {
+		defer close(dispatched)
+		var idx int64
+		for serial := range serials {
+			select {
+			case dispatched <- indexedSerial{serial: serial, index: idx}:
+			case <-ctx.Done():
+				return
+			}
+			idx++
+		}
+	}()
+
+	var mu sync.Mutex
+	processedSinceCheckpoint := 0
+	nextExpected := resumeThreshold + 1
+	pending := make(map[int64]string)
+
+	// finish records the outcome of processing is, and advances the
+	// checkpoint's LastIndex through the longest contiguous run of
+	// completed indices starting at nextExpected. Indices can complete
+	// out of order across workers; only advancing through a contiguous
+	// run guarantees that everything at or before LastIndex has actually
+	// been handled, so a resumed run can safely skip up through it.
+	finish := func(is indexedSerial, outcome revocationOutcome, callErr error) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		checkpoint.Counts[outcome]++
+		processedSinceCheckpoint++
+
+		if summary != nil {
+			result := revocationResult{Serial: is.serial, Outcome: outcome}
+			if callErr != nil {
+				result.Error = callErr.Error()
+			}
+			line, err := json.Marshal(result)
+			if err != nil {
+				return fmt.Errorf("marshalling result for serial %q: %w", is.serial, err)
+			}
+			_, err = summary.Write(append(line, '\n'))
+			if err != nil {
+				return fmt.Errorf("writing summary for serial %q: %w", is.serial, err)
+			}
+		}
+
+		pending[is.index] = is.serial
+		for {
+			serial, ok := pending[nextExpected]
+			if !ok {
+				break
+			}
+			delete(pending, nextExpected)
+			checkpoint.LastIndex = nextExpected
+			checkpoint.LastSerial = serial
+			nextExpected++
+		}
+
+		if cfg.CheckpointPath != "" && (cfg.CheckpointEvery <= 0 || processedSinceCheckpoint >= cfg.CheckpointEvery) {
+			processedSinceCheckpoint = 0
+			err := checkpoint.save(cfg.CheckpointPath)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, parallelism)
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for is := range dispatched {
+				if is.index <= resumeThreshold {
+					err := finish(is, outcomeSkipped, nil)
+					if err != nil {
+						errs <- err
+						return
+					}
+					continue
+				}
+
+				if limiter != nil {
+					err := limiter.Wait(ctx)
+					if err != nil {
+						errs <- err
+						return
+					}
+				}
+
+				decision := decide(is.serial)
+				_, callErr := a.rac.AdministrativelyRevokeCertificate(ctx, &rapb.AdministrativelyRevokeCertificateRequest{
+					Serial:       is.serial,
+					Code:         int64(decision.Reason),
+					SkipBlockKey: decision.SkipBlockKey,
+					Malformed:    decision.Malformed,
+				})
+
+				var outcome revocationOutcome
+				if callErr == nil {
+					outcome = outcomeRevoked
+				} else if berrors.Is(callErr, berrors.AlreadyRevoked) {
+					a.log.Infof("not revoking %q: already revoked", is.serial)
+					outcome = outcomeAlreadyRevoked
+					callErr = nil
+				} else {
+					a.log.Errf("failed to revoke %q: %s", is.serial, callErr)
+					outcome = outcomeFailed
+				}
+
+				err := finish(is, outcome, callErr)
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.CheckpointPath != "" {
+		return checkpoint.save(cfg.CheckpointPath)
+	}
+	return nil
+}
+
+// serialChan turns a materialized slice of serials into the channel form
+// revokeSerials consumes. The serialsFrom* sources above all stream
+// directly and should be passed to revokeSerials as-is; this helper exists
+// for callers (mainly tests) that already have a small, in-memory list of
+// serials to revoke.
+func serialChan(serials []string) <-chan string {
+	ch := make(chan string, len(serials))
+	for _, s := range serials {
+		ch <- s
+	}
+	close(ch)
+	return ch
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path"
@@ -62,6 +65,19 @@ func (c *mockSerialsForIncidentClient) Recv() (*sapb.IncidentSerial, error) {
 	return nil, io.EOF
 }
 
+// collectSerials drains a serialsFrom* result to completion and returns
+// everything it produced, failing the test if it returned an error.
+func collectSerials(t *testing.T, ch <-chan string, errc <-chan error) []string {
+	t.Helper()
+	var res []string
+	for serial := range ch {
+		res = append(res, serial)
+	}
+	err := <-errc
+	test.AssertNotError(t, err, "streaming serials")
+	return res
+}
+
 func TestSerialsFromIncidentTable(t *testing.T) {
 	t.Parallel()
 	serials := []string{"foo", "bar", "baz"}
@@ -70,9 +86,8 @@ func TestSerialsFromIncidentTable(t *testing.T) {
 		saroc: &mockSAWithIncident{incidentSerials: serials},
 	}
 
-	res, err := a.serialsFromIncidentTable(context.Background(), "tablename")
-	test.AssertNotError(t, err, "getting serials from mock SA")
-	test.AssertDeepEquals(t, res, serials)
+	ch, errc := a.serialsFromIncidentTable(context.Background(), "tablename")
+	test.AssertDeepEquals(t, collectSerials(t, ch, errc), serials)
 }
 
 func TestSerialsFromFile(t *testing.T) {
@@ -85,9 +100,8 @@ func TestSerialsFromFile(t *testing.T) {
 
 	a := admin{}
 
-	res, err := a.serialsFromFile(context.Background(), serialsFile)
-	test.AssertNotError(t, err, "getting serials from file")
-	test.AssertDeepEquals(t, res, serials)
+	ch, errc := a.serialsFromFile(context.Background(), serialsFile)
+	test.AssertDeepEquals(t, collectSerials(t, ch, errc), serials)
 }
 
 func TestSerialsFromPrivateKey(t *testing.T) {
@@ -125,9 +139,8 @@ func TestSerialsFromPrivateKey(t *testing.T) {
 
 	a := admin{dbMap: dbMap}
 
-	res, err := a.serialsFromPrivateKey(context.Background(), keyFile)
-	test.AssertNotError(t, err, "getting serials from keyHashToSerial table")
-	test.AssertDeepEquals(t, res, serials)
+	ch, errc := a.serialsFromPrivateKey(context.Background(), keyFile)
+	test.AssertDeepEquals(t, collectSerials(t, ch, errc), serials)
 }
 
 // mockSAWithRegistration is a mock which only implements the GetRegistration
@@ -176,9 +189,8 @@ func TestSerialsFromRegID(t *testing.T) {
 
 	a := admin{saroc: &mockSAWithRegistration{regID: 123}, dbMap: dbMap}
 
-	res, err := a.serialsFromRegID(context.Background(), 123)
-	test.AssertNotError(t, err, "getting serials from serials table")
-	test.AssertDeepEquals(t, res, serials)
+	ch, errc := a.serialsFromRegID(context.Background(), 123)
+	test.AssertDeepEquals(t, collectSerials(t, ch, errc), serials)
 }
 
 // mockRARecordingRevocations is a mock which only implements the
@@ -235,7 +247,7 @@ func TestRevokeSerials(t *testing.T) {
 	mra.reset()
 	log.Clear()
 	a.dryRun = false
-	err := a.revokeSerials(context.Background(), serials, 0, false, false, 1)
+	err := a.revokeSerials(context.Background(), serialChan(serials), constantDecider(0, false, false), 1, revokeSerialsConfig{})
 	test.AssertNotError(t, err, "")
 	test.AssertEquals(t, len(log.GetAll()), 0)
 	test.AssertEquals(t, len(mra.revocationRequests), 3)
@@ -245,7 +257,7 @@ func TestRevokeSerials(t *testing.T) {
 	mra.reset()
 	log.Clear()
 	mra.alreadyRevoked = []string{"foo"}
-	err = a.revokeSerials(context.Background(), serials, 0, false, false, 1)
+	err = a.revokeSerials(context.Background(), serialChan(serials), constantDecider(0, false, false), 1, revokeSerialsConfig{})
 	test.AssertNotError(t, err, "")
 	test.AssertEquals(t, len(log.GetAllMatching("not revoking")), 1)
 	test.AssertEquals(t, len(mra.revocationRequests), 3)
@@ -255,7 +267,7 @@ func TestRevokeSerials(t *testing.T) {
 	mra.reset()
 	log.Clear()
 	mra.doomedToFail = []string{"bar"}
-	err = a.revokeSerials(context.Background(), serials, 0, false, false, 1)
+	err = a.revokeSerials(context.Background(), serialChan(serials), constantDecider(0, false, false), 1, revokeSerialsConfig{})
 	test.AssertNotError(t, err, "")
 	test.AssertEquals(t, len(log.GetAllMatching("failed to revoke")), 1)
 	test.AssertEquals(t, len(mra.revocationRequests), 3)
@@ -264,7 +276,7 @@ func TestRevokeSerials(t *testing.T) {
 	// Revoking with other parameters should get carried through.
 	mra.reset()
 	log.Clear()
-	err = a.revokeSerials(context.Background(), serials, 1, true, true, 3)
+	err = a.revokeSerials(context.Background(), serialChan(serials), constantDecider(1, true, true), 3, revokeSerialsConfig{})
 	test.AssertNotError(t, err, "")
 	test.AssertEquals(t, len(mra.revocationRequests), 3)
 	assertRequestsContain(mra.revocationRequests, 1, true, true)
@@ -274,9 +286,186 @@ func TestRevokeSerials(t *testing.T) {
 	log.Clear()
 	a.dryRun = true
 	a.rac = dryRunRAC{log: log}
-	err = a.revokeSerials(context.Background(), serials, 0, false, false, 1)
+	err = a.revokeSerials(context.Background(), serialChan(serials), constantDecider(0, false, false), 1, revokeSerialsConfig{})
 	test.AssertNotError(t, err, "")
 	test.AssertEquals(t, len(log.GetAllMatching("dry-run:")), 3)
 	test.AssertEquals(t, len(mra.revocationRequests), 0)
 	assertRequestsContain(mra.revocationRequests, 0, false, false)
 }
+
+func TestRevokeSerialsPerSerialDecision(t *testing.T) {
+	t.Parallel()
+	serials := []string{"foo", "bar", "baz"}
+
+	mra := mockRARecordingRevocations{}
+	log := blog.NewMock()
+	a := admin{rac: &mra, log: log}
+
+	// A multi-cause bulk revocation: "foo" is a key compromise, everything
+	// else is superseded. This is exactly what a single global
+	// reasonCode/skipBlockKey/malformed triple couldn't express.
+	decide := func(serial string) revocationDecision {
+		if serial == "foo" {
+			return revocationDecision{Reason: revocation.KeyCompromise}
+		}
+		return revocationDecision{Reason: revocation.Superseded, Malformed: true}
+	}
+
+	err := a.revokeSerials(context.Background(), serialChan(serials), decide, 1, revokeSerialsConfig{})
+	test.AssertNotError(t, err, "revoking with a per-serial decision")
+	test.AssertEquals(t, len(mra.revocationRequests), 3)
+
+	for _, req := range mra.revocationRequests {
+		if req.Serial == "foo" {
+			test.AssertEquals(t, req.Code, int64(revocation.KeyCompromise))
+			test.AssertEquals(t, req.Malformed, false)
+		} else {
+			test.AssertEquals(t, req.Code, int64(revocation.Superseded))
+			test.AssertEquals(t, req.Malformed, true)
+		}
+	}
+}
+
+func TestRevokeSerialsSummary(t *testing.T) {
+	t.Parallel()
+	serials := []string{"foo", "bar", "baz"}
+
+	mra := mockRARecordingRevocations{alreadyRevoked: []string{"bar"}}
+	log := blog.NewMock()
+	a := admin{rac: &mra, log: log}
+
+	summaryPath := path.Join(t.TempDir(), "summary.jsonl")
+	err := a.revokeSerials(context.Background(), serialChan(serials), constantDecider(0, false, false), 1, revokeSerialsConfig{
+		SummaryPath: summaryPath,
+	})
+	test.AssertNotError(t, err, "revoking with a summary path")
+
+	f, err := os.Open(summaryPath)
+	test.AssertNotError(t, err, "opening summary file")
+	defer f.Close()
+
+	outcomes := make(map[string]revocationOutcome)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var result revocationResult
+		err := json.Unmarshal(scanner.Bytes(), &result)
+		test.AssertNotError(t, err, "parsing summary line")
+		outcomes[result.Serial] = result.Outcome
+	}
+	test.AssertEquals(t, len(outcomes), 3)
+	test.AssertEquals(t, outcomes["foo"], outcomeRevoked)
+	test.AssertEquals(t, outcomes["bar"], outcomeAlreadyRevoked)
+	test.AssertEquals(t, outcomes["baz"], outcomeRevoked)
+}
+
+func TestRevokeSerialsCheckpointResume(t *testing.T) {
+	t.Parallel()
+	serials := []string{"foo", "bar", "baz"}
+
+	mra := mockRARecordingRevocations{}
+	log := blog.NewMock()
+	a := admin{rac: &mra, log: log}
+
+	checkpointPath := path.Join(t.TempDir(), "progress.ckpt")
+	err := (&revokeCheckpoint{LastIndex: 1, LastSerial: "bar", Counts: map[revocationOutcome]int64{outcomeRevoked: 2}}).save(checkpointPath)
+	test.AssertNotError(t, err, "seeding checkpoint file")
+
+	err = a.revokeSerials(context.Background(), serialChan(serials), constantDecider(0, false, false), 1, revokeSerialsConfig{
+		CheckpointPath: checkpointPath,
+	})
+	test.AssertNotError(t, err, "resuming from checkpoint")
+
+	// Only "baz" (index 2) comes after the checkpointed index, so only it
+	// should have been sent to the RA.
+	test.AssertEquals(t, len(mra.revocationRequests), 1)
+	test.AssertEquals(t, mra.revocationRequests[0].Serial, "baz")
+
+	checkpoint, err := loadCheckpoint(checkpointPath)
+	test.AssertNotError(t, err, "reading updated checkpoint")
+	test.AssertEquals(t, checkpoint.LastIndex, int64(2))
+	test.AssertEquals(t, checkpoint.LastSerial, "baz")
+}
+
+// TestRevokeSerialsCheckpointResumeParallel is the parallelism>1 analog of
+// TestRevokeSerialsCheckpointResume: with many workers pulling from the
+// input channel concurrently, the skip/process decision for each serial
+// must still depend only on its position in the input order, not on the
+// order workers happen to finish in.
+func TestRevokeSerialsCheckpointResumeParallel(t *testing.T) {
+	t.Parallel()
+	var serials []string
+	for i := 0; i < 200; i++ {
+		serials = append(serials, fmt.Sprintf("serial-%03d", i))
+	}
+
+	mra := mockRARecordingRevocations{}
+	log := blog.NewMock()
+	a := admin{rac: &mra, log: log}
+
+	checkpointPath := path.Join(t.TempDir(), "progress.ckpt")
+	err := (&revokeCheckpoint{LastIndex: 99, LastSerial: serials[99], Counts: map[revocationOutcome]int64{outcomeRevoked: 100}}).save(checkpointPath)
+	test.AssertNotError(t, err, "seeding checkpoint file")
+
+	err = a.revokeSerials(context.Background(), serialChan(serials), constantDecider(0, false, false), 16, revokeSerialsConfig{
+		CheckpointPath: checkpointPath,
+	})
+	test.AssertNotError(t, err, "resuming from checkpoint with many workers")
+
+	// Every serial from index 100 onward must have been revoked, and
+	// nothing before it, regardless of which worker handled which index.
+	test.AssertEquals(t, len(mra.revocationRequests), 100)
+	seen := make(map[string]bool)
+	for _, req := range mra.revocationRequests {
+		seen[req.Serial] = true
+	}
+	for i, serial := range serials {
+		if i < 100 {
+			test.Assert(t, !seen[serial], fmt.Sprintf("serial %q should have been skipped as already checkpointed", serial))
+		} else {
+			test.Assert(t, seen[serial], fmt.Sprintf("serial %q should have been revoked", serial))
+		}
+	}
+
+	checkpoint, err := loadCheckpoint(checkpointPath)
+	test.AssertNotError(t, err, "reading updated checkpoint")
+	test.AssertEquals(t, checkpoint.LastIndex, int64(199))
+	test.AssertEquals(t, checkpoint.LastSerial, serials[199])
+}
+
+// TestRevokeSerialsPersistentFinishErrorDoesNotDeadlock covers a worker pool
+// where finish() fails for every serial, not just the first one. Each of
+// the parallelism workers can hit its own error before wg.Wait() ever
+// drains errs, so if a worker kept pulling from dispatched after its first
+// error instead of stopping, more than parallelism errors could pile up
+// against the channel's buffer and every worker would block forever on
+// errs <- err.
+func TestRevokeSerialsPersistentFinishErrorDoesNotDeadlock(t *testing.T) {
+	t.Parallel()
+	var serials []string
+	for i := 0; i < 200; i++ {
+		serials = append(serials, fmt.Sprintf("serial-%03d", i))
+	}
+
+	mra := mockRARecordingRevocations{}
+	log := blog.NewMock()
+	a := admin{rac: &mra, log: log}
+
+	// CheckpointPath's parent directory doesn't exist, so loadCheckpoint
+	// falls back to a fresh checkpoint, but every subsequent checkpoint.save
+	// call (one per finish(), since CheckpointEvery is unset) fails.
+	checkpointPath := path.Join(t.TempDir(), "missing-dir", "progress.ckpt")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- a.revokeSerials(context.Background(), serialChan(serials), constantDecider(0, false, false), 16, revokeSerialsConfig{
+			CheckpointPath: checkpointPath,
+		})
+	}()
+
+	select {
+	case err := <-done:
+		test.AssertError(t, err, "revoking with an unwritable checkpoint path")
+	case <-time.After(10 * time.Second):
+		t.Fatal("revokeSerials deadlocked instead of returning the first finish() error")
+	}
+}
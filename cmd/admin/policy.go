@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/letsencrypt/boulder/revocation"
+)
+
+// revocationDecision is what the policy engine resolves a given serial to:
+// the reason code to revoke it with, whether to skip blocking its key, and
+// whether to mark it malformed. It's the per-serial replacement for the
+// reasonCode/skipBlockKey/malformed arguments revokeSerials used to take as
+// one global triple for the whole run.
+type revocationDecision struct {
+	Reason       revocation.Reason
+	SkipBlockKey bool
+	Malformed    bool
+}
+
+// revocationRule is one entry of a revocation policy file. Source matches
+// against the name of the serialsFrom* flag that produced a given serial
+// (e.g. "private-key", "incident-table", "reg-id", "file"); a rule with an
+// empty Source matches any source that no earlier rule matched.
+//
+// SerialPrefix, if set, additionally restricts the rule to serials that
+// start with it, so a single source (most often "incident-table" or
+// "file", which can mix serials from unrelated causes) can still be split
+// across several reasons within one policy file. A rule with an empty
+// SerialPrefix matches any serial.
+type revocationRule struct {
+	Source       string            `yaml:"source"`
+	SerialPrefix string            `yaml:"serialPrefix"`
+	Reason       revocation.Reason `yaml:"reason"`
+	SkipBlockKey bool              `yaml:"skipBlockKey"`
+	Malformed    bool              `yaml:"malformed"`
+}
+
+// revocationPolicy is a YAML-defined set of rules mapping a serial's source
+// to the revocationDecision it should get, loaded with --policy and applied
+// to every serial revokeSerials processes. Rules are evaluated in order;
+// the first whose Source matches (or is empty) and whose SerialPrefix
+// matches (or is empty) wins.
+type revocationPolicy struct {
+	Rules []revocationRule `yaml:"rules"`
+}
+
+// loadRevocationPolicy parses a revocation policy from the given YAML file.
+func loadRevocationPolicy(path string) (*revocationPolicy, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading revocation policy %q: %w", path, err)
+	}
+
+	var p revocationPolicy
+	err = yaml.Unmarshal(contents, &p)
+	if err != nil {
+		return nil, fmt.Errorf("parsing revocation policy %q: %w", path, err)
+	}
+	return &p, nil
+}
+
+// decisionFor resolves the revocationDecision for the given serial, drawn
+// from the given source, per this policy's rules. Rules are matched in
+// order against both Source and SerialPrefix, so two serials pulled from
+// the same source can still resolve to different decisions. If no rule
+// matches, it returns the zero decision (reason=unspecified, nothing
+// skipped).
+func (p *revocationPolicy) decisionFor(source string, serial string) revocationDecision {
+	for _, rule := range p.Rules {
+		if (rule.Source == "" || rule.Source == source) &&
+			(rule.SerialPrefix == "" || strings.HasPrefix(serial, rule.SerialPrefix)) {
+			return revocationDecision{
+				Reason:       rule.Reason,
+				SkipBlockKey: rule.SkipBlockKey,
+				Malformed:    rule.Malformed,
+			}
+		}
+	}
+	return revocationDecision{}
+}
+
+// decider returns a per-serial decision function bound to a single source,
+// for use as the decide argument to revokeSerials. It re-evaluates the
+// policy for every serial, so rules keyed on SerialPrefix apply correctly
+// even when a single serialsFrom* source mixes serials that should be
+// revoked for different reasons.
+func (p *revocationPolicy) decider(source string) func(serial string) revocationDecision {
+	return func(serial string) revocationDecision {
+		return p.decisionFor(source, serial)
+	}
+}
+
+// constantDecider returns a decide function that returns the same decision
+// for every serial, for callers (and tests) that don't need a policy file.
+func constantDecider(reason revocation.Reason, skipBlockKey bool, malformed bool) func(serial string) revocationDecision {
+	decision := revocationDecision{Reason: reason, SkipBlockKey: skipBlockKey, Malformed: malformed}
+	return func(serial string) revocationDecision {
+		return decision
+	}
+}
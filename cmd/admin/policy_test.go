@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/letsencrypt/boulder/revocation"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestRevocationPolicyDecisionFor(t *testing.T) {
+	t.Parallel()
+
+	p := &revocationPolicy{
+		Rules: []revocationRule{
+			{Source: "private-key", Reason: revocation.KeyCompromise, SkipBlockKey: false},
+			{Source: "incident-table", Reason: revocation.Superseded, Malformed: true},
+			{Reason: revocation.Unspecified},
+		},
+	}
+
+	test.AssertEquals(t, p.decisionFor("private-key", "0102").Reason, revocation.KeyCompromise)
+	test.AssertEquals(t, p.decisionFor("private-key", "0102").SkipBlockKey, false)
+
+	test.AssertEquals(t, p.decisionFor("incident-table", "0304").Reason, revocation.Superseded)
+	test.AssertEquals(t, p.decisionFor("incident-table", "0304").Malformed, true)
+
+	// A source with no matching rule falls through to the catch-all.
+	test.AssertEquals(t, p.decisionFor("file", "0506").Reason, revocation.Unspecified)
+}
+
+func TestRevocationPolicyDecisionForSerialPrefix(t *testing.T) {
+	t.Parallel()
+
+	p := &revocationPolicy{
+		Rules: []revocationRule{
+			{Source: "incident-table", SerialPrefix: "00aa", Reason: revocation.KeyCompromise},
+			{Source: "incident-table", Reason: revocation.Superseded},
+		},
+	}
+
+	// Same source, different serial prefixes: each should resolve to its
+	// own rule, not whichever rule for that source comes first.
+	test.AssertEquals(t, p.decisionFor("incident-table", "00aabbcc").Reason, revocation.KeyCompromise)
+	test.AssertEquals(t, p.decisionFor("incident-table", "00bbccdd").Reason, revocation.Superseded)
+}
+
+func TestLoadRevocationPolicy(t *testing.T) {
+	t.Parallel()
+
+	policyPath := path.Join(t.TempDir(), "policy.yaml")
+	err := os.WriteFile(policyPath, []byte(`
+rules:
+  - source: private-key
+    reason: 1
+    skipBlockKey: false
+  - source: incident-table
+    reason: 4
+    malformed: true
+`), 0644)
+	test.AssertNotError(t, err, "writing test policy file")
+
+	p, err := loadRevocationPolicy(policyPath)
+	test.AssertNotError(t, err, "loading policy file")
+	test.AssertEquals(t, len(p.Rules), 2)
+	test.AssertEquals(t, p.Rules[0].Source, "private-key")
+	test.AssertEquals(t, p.Rules[0].Reason, revocation.KeyCompromise)
+	test.AssertEquals(t, p.Rules[1].Malformed, true)
+}
+
+func TestDeciderAppliesSameDecisionToEverySerialFromOneSource(t *testing.T) {
+	t.Parallel()
+
+	p := &revocationPolicy{
+		Rules: []revocationRule{
+			{Source: "private-key", Reason: revocation.KeyCompromise},
+		},
+	}
+
+	decide := p.decider("private-key")
+	for _, serial := range []string{"foo", "bar", "baz"} {
+		d := decide(serial)
+		test.AssertEquals(t, d.Reason, revocation.KeyCompromise)
+	}
+}
+
+func TestDeciderVariesBySerialWithinOneSource(t *testing.T) {
+	t.Parallel()
+
+	p := &revocationPolicy{
+		Rules: []revocationRule{
+			{Source: "incident-table", SerialPrefix: "00aa", Reason: revocation.KeyCompromise},
+			{Source: "incident-table", Reason: revocation.Superseded},
+		},
+	}
+
+	decide := p.decider("incident-table")
+	test.AssertEquals(t, decide("00aabbcc").Reason, revocation.KeyCompromise)
+	test.AssertEquals(t, decide("00bbccdd").Reason, revocation.Superseded)
+}
@@ -0,0 +1,87 @@
+// rocsp-tool populates Redis with pre-signed OCSP responses for every
+// unexpired, unrevoked certificate, and keeps them fresh as certificates
+// are revoked. This file holds the client type and the issuer-loading and
+// starting-ID helpers shared by its subcommands.
+package notmain
+
+import (
+	"context"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/rocsp"
+)
+
+// issuer bundles together an issuing certificate with the short numeric ID
+// used to tag rows in the certificateStatus table and to key entries in
+// Redis.
+type issuer struct {
+	cert *x509.Certificate
+	id   int
+}
+
+// loadIssuers reads each issuer certificate named in certFiles from disk
+// and returns them indexed by the issuer ID used in the certificateStatus
+// table.
+func loadIssuers(certFiles map[string]int) (map[int]*issuer, error) {
+	issuers := make(map[int]*issuer, len(certFiles))
+	for path, id := range certFiles {
+		cert, err := core.LoadCert(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading issuer cert %q: %w", path, err)
+		}
+		issuers[id] = &issuer{cert: cert, id: id}
+	}
+	return issuers, nil
+}
+
+// getStartingID returns the smallest certificateStatus.id whose NotAfter is
+// still in the future, relative to clk. Subcommands that scan
+// certificateStatus from oldest to newest start here, so they don't waste
+// time re-scanning rows for certificates that have already expired.
+func getStartingID(ctx context.Context, clk clock.Clock, db *sql.DB) (int64, error) {
+	var startingID int64
+	err := db.QueryRowContext(ctx,
+		"SELECT MIN(id) FROM certificateStatus WHERE notAfter > ?", clk.Now()).Scan(&startingID)
+	if err != nil {
+		return 0, fmt.Errorf("finding starting certificateStatus id: %w", err)
+	}
+	return startingID, nil
+}
+
+// client bundles the issuers, Redis writer, certificateStatus database
+// handle and OCSP generator rocsp-tool works with. The only method defined
+// on it here is storeResponse, which writes an already-signed response to
+// Redis; scanning certificateStatus and signing responses are not
+// implemented in this file.
+type client struct {
+	issuers       map[int]*issuer
+	redis         *rocsp.WritingClient
+	db            *sql.DB
+	ocspGenerator ocspGenerator
+	clk           clock.Clock
+}
+
+// ocspGenerator produces a signed OCSP response for a certificate status;
+// it's an interface so tests can substitute the real CA's signer.
+type ocspGenerator interface {
+	GenerateOCSP(ctx context.Context, serial string) ([]byte, error)
+}
+
+// storeResponse writes a pre-signed, DER-encoded OCSP response into Redis
+// with the given TTL, keyed by the serial number embedded in the response
+// itself.
+func (c *client) storeResponse(ctx context.Context, response []byte, ttl *time.Duration) error {
+	parsed, err := ocsp.ParseResponse(response, nil)
+	if err != nil {
+		return fmt.Errorf("parsing OCSP response to store: %w", err)
+	}
+
+	return c.redis.StoreResponse(ctx, response, parsed.SerialNumber, *ttl)
+}
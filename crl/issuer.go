@@ -0,0 +1,168 @@
+// Package crl generates X.509 certificate revocation lists, both full and
+// delta, from the same revoked-certificate state that cmd/rocsp-tool feeds
+// into Redis as pre-signed OCSP responses.
+package crl
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/jmhodges/clock"
+
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// deltaCRLIndicatorOID is the OID for the DeltaCRLIndicator extension
+// (RFC 5280 §5.2.4): a critical extension on a delta CRL naming the CRL
+// number of the full CRL it's relative to.
+var deltaCRLIndicatorOID = asn1.ObjectIdentifier{2, 5, 29, 27}
+
+// Issuer issues full and delta CRLs for one or more issuing certificates,
+// tracking CRL numbers and persisted DER bytes in the database so a
+// restarted process can resume cleanly and delta CRLs can diff against
+// whatever full CRL preceded them.
+type Issuer struct {
+	db  *sql.DB
+	clk clock.Clock
+	log blog.Logger
+
+	// keys holds the issuing certificate and signing key for each issuer
+	// ID, as passed to NewIssuer. The IDs are the same certificateStatus
+	// issuer IDs cmd/rocsp-tool's loadIssuers uses; loadIssuers itself
+	// loads only certificates, not signing keys.
+	keys map[int64]issuerKeyMaterial
+}
+
+// issuerKeyMaterial is the certificate and private key needed to sign a
+// CRL on behalf of one issuer.
+type issuerKeyMaterial struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// NewIssuer constructs a crl.Issuer. keys must contain, for every issuerID
+// that IssueFull or IssueDelta will be called with, the certificate and
+// key that issuer signs with.
+func NewIssuer(db *sql.DB, clk clock.Clock, log blog.Logger, keys map[int64]issuerKeyMaterial) *Issuer {
+	return &Issuer{db: db, clk: clk, log: log, keys: keys}
+}
+
+// IssueFull generates and persists a full CRL for the given issuer,
+// containing the certificates revoked under it that revokedCertificates
+// scans (rows before the first unexpired certificate are skipped). It
+// returns the CRL's DER
+// encoding and its CRL number.
+func (i *Issuer) IssueFull(ctx context.Context, issuerID int64) ([]byte, *big.Int, error) {
+	conn, unlock, err := i.lockIssuer(ctx, issuerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	km, ok := i.keys[issuerID]
+	if !ok {
+		return nil, nil, fmt.Errorf("no key material loaded for issuer %d", issuerID)
+	}
+
+	crlNumber, err := i.nextCRLNumber(ctx, conn, issuerID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	revoked, err := i.revokedCertificates(ctx, conn, issuerID, time.Time{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := i.clk.Now()
+	template := &x509.RevocationList{
+		Number:              crlNumber,
+		ThisUpdate:          now,
+		NextUpdate:          now.Add(7 * 24 * time.Hour),
+		RevokedCertificates: revoked,
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, template, km.cert, km.key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing full CRL for issuer %d: %w", issuerID, err)
+	}
+
+	err = i.storeCRL(ctx, conn, issuerID, crlNumber, der, false, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return der, crlNumber, nil
+}
+
+// IssueDelta generates and persists a delta CRL for the given issuer,
+// relative to baseCRLNumber: it contains only certificates revoked since
+// the full CRL with that number was issued. It returns the delta CRL's DER
+// encoding and its own (new) CRL number.
+func (i *Issuer) IssueDelta(ctx context.Context, issuerID int64, baseCRLNumber *big.Int) ([]byte, *big.Int, error) {
+	conn, unlock, err := i.lockIssuer(ctx, issuerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	km, ok := i.keys[issuerID]
+	if !ok {
+		return nil, nil, fmt.Errorf("no key material loaded for issuer %d", issuerID)
+	}
+
+	baseThisUpdate, err := i.crlThisUpdate(ctx, conn, issuerID, baseCRLNumber)
+	if err != nil {
+		return nil, nil, fmt.Errorf("looking up base CRL %s for issuer %d: %w", baseCRLNumber, issuerID, err)
+	}
+
+	crlNumber, err := i.nextCRLNumber(ctx, conn, issuerID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	revoked, err := i.revokedCertificates(ctx, conn, issuerID, baseThisUpdate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deltaIndicator, err := asn1.Marshal(baseCRLNumber)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding delta CRL indicator: %w", err)
+	}
+
+	now := i.clk.Now()
+	template := &x509.RevocationList{
+		Number:              crlNumber,
+		ThisUpdate:          now,
+		NextUpdate:          now.Add(24 * time.Hour),
+		RevokedCertificates: revoked,
+		ExtraExtensions: []pkix.Extension{
+			{
+				Id:       deltaCRLIndicatorOID,
+				Critical: true,
+				Value:    deltaIndicator,
+			},
+		},
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, template, km.cert, km.key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing delta CRL for issuer %d: %w", issuerID, err)
+	}
+
+	err = i.storeCRL(ctx, conn, issuerID, crlNumber, der, true, baseCRLNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return der, crlNumber, nil
+}
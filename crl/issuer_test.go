@@ -0,0 +1,173 @@
+package crl
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmhodges/clock"
+
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/test"
+	"github.com/letsencrypt/boulder/test/vars"
+)
+
+// makeTestIssuerCert returns a self-signed CA certificate and its signing
+// key, suitable for signing test CRLs.
+func makeTestIssuerCert(t *testing.T) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "generating test issuer key")
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Issuer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	test.AssertNotError(t, err, "creating test issuer certificate")
+
+	cert, err := x509.ParseCertificate(der)
+	test.AssertNotError(t, err, "parsing test issuer certificate")
+	return cert, key
+}
+
+// insertRevoked inserts a single revoked certificateStatus row for use by
+// revokedCertificates.
+func insertRevoked(t *testing.T, db *sql.DB, issuerID int64, serialHex string, revokedDate time.Time, revokedReason int, notAfter time.Time) {
+	t.Helper()
+	_, err := db.ExecContext(context.Background(),
+		`INSERT INTO certificateStatus (serial, issuerID, status, revokedDate, revokedReason, notAfter)
+		 VALUES (?, ?, 'revoked', ?, ?, ?)`,
+		serialHex, issuerID, revokedDate, revokedReason, notAfter)
+	test.AssertNotError(t, err, "inserting test revoked certificate")
+}
+
+func TestIssueFullAndDeltaRoundTrip(t *testing.T) {
+	t.Parallel()
+	const issuerID = int64(9001)
+
+	clk := clock.NewFake()
+	clk.Set(time.Now())
+
+	db, err := sql.Open("mysql", vars.DBConnSA)
+	test.AssertNotError(t, err, "opening test db")
+	defer db.Close()
+	defer test.ResetBoulderTestDatabase(t)
+
+	issuerCert, issuerKey := makeTestIssuerCert(t)
+	issuer := NewIssuer(db, clk, blog.NewMock(), map[int64]issuerKeyMaterial{
+		issuerID: {cert: issuerCert, key: issuerKey},
+	})
+
+	ctx := context.Background()
+
+	// Seed one certificate revoked before the full CRL is issued.
+	insertRevoked(t, db, issuerID, "01", clk.Now().Add(-time.Hour), 1, clk.Now().Add(24*time.Hour))
+
+	fullDER, fullNumber, err := issuer.IssueFull(ctx, issuerID)
+	test.AssertNotError(t, err, "issuing full CRL")
+
+	fullCRL, err := x509.ParseRevocationList(fullDER)
+	test.AssertNotError(t, err, "parsing full CRL")
+	test.AssertEquals(t, fullCRL.Number.Cmp(fullNumber), 0)
+	test.AssertEquals(t, len(fullCRL.RevokedCertificates), 1)
+	test.AssertEquals(t, fullCRL.RevokedCertificates[0].SerialNumber.Text(16), "1")
+
+	// Seed a second certificate, revoked after the full CRL's thisUpdate,
+	// and advance the clock so it falls after the base CRL's cutoff.
+	clk.Add(2 * time.Hour)
+	insertRevoked(t, db, issuerID, "02", clk.Now(), 4, clk.Now().Add(24*time.Hour))
+
+	deltaDER, deltaNumber, err := issuer.IssueDelta(ctx, issuerID, fullNumber)
+	test.AssertNotError(t, err, "issuing delta CRL")
+	test.Assert(t, deltaNumber.Cmp(fullNumber) > 0, "delta CRL number should be greater than the full CRL's")
+
+	deltaCRL, err := x509.ParseRevocationList(deltaDER)
+	test.AssertNotError(t, err, "parsing delta CRL")
+	test.AssertEquals(t, deltaCRL.Number.Cmp(deltaNumber), 0)
+
+	// The delta must contain only the certificate revoked after the full
+	// CRL, not the one already covered by it.
+	test.AssertEquals(t, len(deltaCRL.RevokedCertificates), 1)
+	test.AssertEquals(t, deltaCRL.RevokedCertificates[0].SerialNumber.Text(16), "2")
+
+	foundDeltaIndicator := false
+	for _, ext := range deltaCRL.Extensions {
+		if ext.Id.Equal(deltaCRLIndicatorOID) {
+			foundDeltaIndicator = true
+		}
+	}
+	test.Assert(t, foundDeltaIndicator, "delta CRL should carry a DeltaCRLIndicator extension")
+}
+
+func TestLockIssuerSerializesConcurrentCallers(t *testing.T) {
+	t.Parallel()
+	const issuerID = int64(9002)
+
+	db, err := sql.Open("mysql", vars.DBConnSA)
+	test.AssertNotError(t, err, "opening test db")
+	defer db.Close()
+
+	issuer := &Issuer{db: db, clk: clock.New(), log: blog.NewMock()}
+
+	var mu sync.Mutex
+	active := 0
+	maxActive := 0
+
+	var wg sync.WaitGroup
+	for n := 0; n < 5; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.Background()
+			conn, unlock, err := issuer.lockIssuer(ctx, issuerID)
+			if err != nil {
+				t.Errorf("acquiring advisory lock: %s", err)
+				return
+			}
+			defer unlock()
+
+			mu.Lock()
+			active++
+			if active > maxActive {
+				maxActive = active
+			}
+			mu.Unlock()
+
+			// Run an intervening query on the pinned connection while
+			// holding the lock, the way IssueFull/IssueDelta do: this is
+			// what catches a lockIssuer that acquires GET_LOCK on one
+			// pooled connection but leaves it free for another query (and
+			// RELEASE_LOCK) to land on a different one.
+			var one int
+			err = conn.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+			if err != nil {
+				t.Errorf("querying on pinned connection: %s", err)
+			}
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	test.AssertEquals(t, maxActive, 1)
+}
@@ -0,0 +1,200 @@
+package crl
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// crlReasonOID is the OID for the CRLReason entry extension (RFC 5280
+// §5.3.1), used to record why each certificate on a CRL was revoked.
+var crlReasonOID = asn1.ObjectIdentifier{2, 5, 29, 21}
+
+// reasonExtension wraps a CRLReason code as the single-extension slice
+// expected on a pkix.RevokedCertificate. A reason of 0 (unspecified) is
+// omitted, matching the usual CA convention of leaving it off when it
+// wouldn't add information.
+func reasonExtension(reason int) []pkix.Extension {
+	if reason == 0 {
+		return nil
+	}
+	value, err := asn1.Marshal(asn1.Enumerated(reason))
+	if err != nil {
+		return nil
+	}
+	return []pkix.Extension{{Id: crlReasonOID, Value: value}}
+}
+
+// dbExecutor is the subset of *sql.DB's (and *sql.Conn's) query methods
+// that the helpers below need. Accepting it, rather than a concrete type,
+// is what lets nextCRLNumber/revokedCertificates/storeCRL/crlThisUpdate
+// run either against the pool (no locking needed) or against a single
+// pinned connection (while an issuer's advisory lock is held on it).
+type dbExecutor interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// lockIssuer takes an advisory lock scoped to a single issuer, so that two
+// concurrently-running CRL generation processes (or two subcommands of the
+// same process) can't race to assign the same CRL number or interleave
+// writes to the same issuer's CRL history.
+//
+// MySQL's GET_LOCK/RELEASE_LOCK are scoped to the session that acquired
+// them, not to the statement, so this pins a single *sql.Conn out of the
+// pool for the lock's entire lifetime rather than issuing GET_LOCK and
+// RELEASE_LOCK through whatever connection the pool happens to hand back
+// next — otherwise the unlock can silently no-op on a different connection
+// while the one actually holding the lock goes back to the idle pool and
+// holds it forever. Callers must run every query made while holding the
+// lock through the returned conn, not i.db, and call the returned unlock
+// func exactly once to release the lock and return the connection.
+func (i *Issuer) lockIssuer(ctx context.Context, issuerID int64) (*sql.Conn, func(), error) {
+	conn, err := i.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("acquiring a database connection for issuer %d: %w", issuerID, err)
+	}
+
+	lockName := fmt.Sprintf("crl-issuer-%d", issuerID)
+
+	var got int
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 30)", lockName).Scan(&got)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("acquiring advisory lock for issuer %d: %w", issuerID, err)
+	}
+	if got != 1 {
+		conn.Close()
+		return nil, nil, fmt.Errorf("timed out acquiring advisory lock for issuer %d", issuerID)
+	}
+
+	return conn, func() {
+		_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+		if err != nil {
+			i.log.Errf("releasing advisory lock for issuer %d: %s", issuerID, err)
+		}
+		err = conn.Close()
+		if err != nil {
+			i.log.Errf("releasing pinned connection for issuer %d: %s", issuerID, err)
+		}
+	}, nil
+}
+
+// nextCRLNumber returns the next monotonically increasing CRL number for
+// the given issuer: one more than the highest number issued so far, or 1
+// if none have been issued yet. Must be called while holding that issuer's
+// advisory lock, through the conn lockIssuer returned.
+func (i *Issuer) nextCRLNumber(ctx context.Context, db dbExecutor, issuerID int64) (*big.Int, error) {
+	var max sql.NullInt64
+	err := db.QueryRowContext(ctx,
+		"SELECT MAX(CAST(crlNumber AS UNSIGNED)) FROM crls WHERE issuerID = ?", issuerID).Scan(&max)
+	if err != nil {
+		return nil, fmt.Errorf("finding latest CRL number for issuer %d: %w", issuerID, err)
+	}
+	if !max.Valid {
+		return big.NewInt(1), nil
+	}
+	return big.NewInt(max.Int64 + 1), nil
+}
+
+// crlThisUpdate returns the thisUpdate time recorded for the full CRL with
+// the given number, so IssueDelta knows the cutoff for "newly revoked
+// since the base CRL."
+func (i *Issuer) crlThisUpdate(ctx context.Context, db dbExecutor, issuerID int64, crlNumber *big.Int) (time.Time, error) {
+	var thisUpdate time.Time
+	err := db.QueryRowContext(ctx,
+		"SELECT thisUpdate FROM crls WHERE issuerID = ? AND crlNumber = ? AND isDelta = 0",
+		issuerID, crlNumber.String()).Scan(&thisUpdate)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return thisUpdate, nil
+}
+
+// storeCRL persists a generated CRL's DER bytes, indexed by issuer and CRL
+// number, so future delta CRLs can diff against it and operators can
+// retrieve any previously-issued CRL.
+func (i *Issuer) storeCRL(ctx context.Context, db dbExecutor, issuerID int64, crlNumber *big.Int, der []byte, isDelta bool, baseCRLNumber *big.Int) error {
+	var base sql.NullString
+	if baseCRLNumber != nil {
+		base = sql.NullString{String: baseCRLNumber.String(), Valid: true}
+	}
+
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO crls (issuerID, crlNumber, thisUpdate, isDelta, baseCRLNumber, der)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		issuerID, crlNumber.String(), i.clk.Now(), isDelta, base, der)
+	if err != nil {
+		return fmt.Errorf("storing CRL %s for issuer %d: %w", crlNumber, issuerID, err)
+	}
+	return nil
+}
+
+// getStartingID returns the smallest certificateStatus.id whose
+// notAfter is still in the future, relative to i.clk. Mirrors
+// cmd/rocsp-tool's getStartingID: it lets a restarted CRL generation scan
+// skip certificateStatus rows for certificates that have already expired,
+// so a restart doesn't re-scan the whole table, while still guaranteeing it
+// won't miss a revocation that landed between runs.
+func (i *Issuer) getStartingID(ctx context.Context, db dbExecutor) (int64, error) {
+	var startingID int64
+	err := db.QueryRowContext(ctx,
+		"SELECT MIN(id) FROM certificateStatus WHERE notAfter > ?", i.clk.Now()).Scan(&startingID)
+	if err != nil {
+		return 0, fmt.Errorf("finding starting certificateStatus id: %w", err)
+	}
+	return startingID, nil
+}
+
+// revokedCertificates returns the certificates revoked under the given
+// issuer with a revokedDate after since (zero means no date cutoff), as
+// pkix.RevokedCertificate entries ready to embed in a CRL. Only
+// certificateStatus rows from getStartingID onward are scanned, so revoked
+// rows before the first unexpired certificate are not included.
+func (i *Issuer) revokedCertificates(ctx context.Context, db dbExecutor, issuerID int64, since time.Time) ([]pkix.RevokedCertificate, error) {
+	startingID, err := i.getStartingID(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT serial, revokedDate, revokedReason FROM certificateStatus
+		 WHERE id >= ? AND issuerID = ? AND status = 'revoked' AND revokedDate > ?`,
+		startingID, issuerID, since)
+	if err != nil {
+		return nil, fmt.Errorf("scanning revoked certificates for issuer %d: %w", issuerID, err)
+	}
+	defer rows.Close()
+
+	var revoked []pkix.RevokedCertificate
+	for rows.Next() {
+		var serialHex string
+		var revokedDate time.Time
+		var revokedReason int
+		err := rows.Scan(&serialHex, &revokedDate, &revokedReason)
+		if err != nil {
+			return nil, fmt.Errorf("reading revoked certificate row: %w", err)
+		}
+
+		serial, ok := new(big.Int).SetString(serialHex, 16)
+		if !ok {
+			return nil, fmt.Errorf("parsing serial %q as hex", serialHex)
+		}
+
+		revoked = append(revoked, pkix.RevokedCertificate{
+			SerialNumber:   serial,
+			RevocationTime: revokedDate,
+			Extensions:     reasonExtension(revokedReason),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating revoked certificates for issuer %d: %w", issuerID, err)
+	}
+
+	return revoked, nil
+}
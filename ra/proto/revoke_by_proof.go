@@ -0,0 +1,55 @@
+package proto
+
+// RevokeCertificateByProofRequest is the wire type for
+// RegistrationAuthority.RevokeCertificateByProof. It is defined alongside
+// the other *Request types in this package, but it's hand-maintained here
+// rather than generated, because this checkout doesn't carry the generated
+// ra.pb.go it would normally live in.
+//
+// ProofType identifies which of the RFC 8555 §7.6 proofs the WFE resolved
+// the requester's JWS to before forwarding the request. The RA re-derives
+// and checks the proof itself from the SA's records (and, for
+// ProofCertificateKey, from the certificate's own public key) rather than
+// trusting this request's say-so; ProofType and the fields below are only
+// what the WFE was able to determine from the JWS, not a pre-verified
+// authorization decision.
+type RevokeCertificateByProofRequest struct {
+	// Cert is the DER encoding of the certificate to revoke.
+	Cert []byte
+	// Reason is the CRLReason code the requester asked for.
+	Reason int64
+	// ProofType is one of the ProofXxx constants below.
+	ProofType int64
+	// AccountID is populated for ProofIssuingAccount, ProofAccountKey, and
+	// ProofIdentifierAuthorizations; it's the account the WFE verified the
+	// JWS against.
+	AccountID int64
+	// CertificateKeyPKIXPublicKey is populated for ProofCertificateKey: the
+	// DER-encoded SubjectPublicKeyInfo of the key the WFE verified the JWS
+	// against. The RA compares it to the certificate's own public key
+	// itself; this field only carries what key to compare, never a
+	// pre-verified "yes it matched" result.
+	CertificateKeyPKIXPublicKey []byte
+}
+
+const (
+	// ProofIssuingAccount is presented when the JWS was signed by the
+	// account key that originally requested the certificate's issuance.
+	ProofIssuingAccount int64 = iota + 1
+	// ProofIdentifierAuthorizations is presented when the JWS was signed by
+	// an account holding valid authorizations for every identifier in the
+	// certificate.
+	ProofIdentifierAuthorizations
+	// ProofCertificateKey is presented when the JWS was signed by the
+	// certificate's own key pair.
+	ProofCertificateKey
+	// ProofAccountKey is presented when the JWS was signed by an account
+	// key ("kid"), without the WFE itself determining whether that account
+	// is the issuing account or merely holds valid authorizations for
+	// every identifier on the certificate: a JWS alone doesn't tell the
+	// WFE which of those two is true, only that it was an account key.
+	// The RA tries ProofIssuingAccount's check first and falls back to
+	// ProofIdentifierAuthorizations's, so RFC 8555 §7.6 case (b) is
+	// reachable even when the WFE can't distinguish it from case (a).
+	ProofAccountKey
+)
@@ -0,0 +1,104 @@
+package ra
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/jmhodges/clock"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/letsencrypt/boulder/core"
+	berrors "github.com/letsencrypt/boulder/errors"
+	blog "github.com/letsencrypt/boulder/log"
+	rapb "github.com/letsencrypt/boulder/ra/proto"
+	"github.com/letsencrypt/boulder/revocation"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// RegistrationAuthorityImpl implements rapb.RegistrationAuthorityServer. It
+// is the only part of Boulder allowed to mutate certificate and
+// registration state, whether that mutation was requested by an operator
+// (cmd/admin) or by a subscriber acting through the WFE.
+type RegistrationAuthorityImpl struct {
+	SA  sapb.StorageAuthorityClient
+	clk clock.Clock
+	log blog.Logger
+}
+
+// NewRegistrationAuthorityImpl constructs a RegistrationAuthorityImpl.
+func NewRegistrationAuthorityImpl(sa sapb.StorageAuthorityClient, clk clock.Clock, log blog.Logger) *RegistrationAuthorityImpl {
+	return &RegistrationAuthorityImpl{SA: sa, clk: clk, log: log}
+}
+
+// AdministrativelyRevokeCertificate revokes the certificate with the given
+// serial on behalf of an operator (see cmd/admin), without requiring proof
+// that the caller controls the certificate or its issuing account.
+func (ra *RegistrationAuthorityImpl) AdministrativelyRevokeCertificate(ctx context.Context, req *rapb.AdministrativelyRevokeCertificateRequest) (*emptypb.Empty, error) {
+	err := ra.revokeBySerial(ctx, "admin-revoker", req.Serial, revocation.Reason(req.Code), req.SkipBlockKey, req.Malformed)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// revokeBySerial marks the given serial revoked and, if the reason is
+// keyCompromise and the caller hasn't opted out, blocks the certificate's
+// public key from future issuance. It's the shared core of every
+// revocation entry point: the administrative path above, and the
+// proof-authorized self-service path in revoke_by_proof.go. source
+// identifies the caller for the blocked-keys audit trail (e.g.
+// "admin-revoker" or "subscriber-self-service") and is passed through
+// unchanged to blockKeyForSerial.
+func (ra *RegistrationAuthorityImpl) revokeBySerial(ctx context.Context, source string, serial string, reasonCode revocation.Reason, skipBlockKey bool, malformed bool) error {
+	_, err := ra.SA.RevokeCertificate(ctx, &sapb.RevokeCertificateRequest{
+		Serial: serial,
+		Reason: int64(reasonCode),
+		Date:   nil,
+	})
+	if err != nil {
+		if berrors.Is(err, berrors.AlreadyRevoked) {
+			return berrors.AlreadyRevokedError("certificate with serial %q is already revoked", serial)
+		}
+		return fmt.Errorf("revoking certificate with serial %q: %w", serial, err)
+	}
+
+	if reasonCode == revocation.KeyCompromise && !skipBlockKey {
+		err = ra.blockKeyForSerial(ctx, source, serial)
+		if err != nil {
+			return fmt.Errorf("blocking key for serial %q: %w", serial, err)
+		}
+	}
+
+	return nil
+}
+
+// blockKeyForSerial looks up the public key hash for a certificate and adds
+// it to the blocked-keys table so no future certificate can be issued for
+// that key. source is recorded on the blocked-keys row so the audit trail
+// reflects which revocation path (admin or subscriber self-service)
+// requested the block.
+func (ra *RegistrationAuthorityImpl) blockKeyForSerial(ctx context.Context, source string, serial string) error {
+	status, err := ra.SA.GetCertificateStatus(ctx, &sapb.Serial{Serial: serial})
+	if err != nil {
+		return fmt.Errorf("looking up certificate status for serial %q: %w", serial, err)
+	}
+
+	cert, err := x509.ParseCertificate(status.RawCert)
+	if err != nil {
+		return fmt.Errorf("parsing certificate for serial %q: %w", serial, err)
+	}
+
+	keyHash, err := core.KeyDigest(cert.PublicKey)
+	if err != nil {
+		return fmt.Errorf("computing SPKI hash for serial %q: %w", serial, err)
+	}
+
+	_, err = ra.SA.AddBlockedKey(ctx, &sapb.AddBlockedKeyRequest{
+		KeyHash: keyHash[:],
+		Added:   timestamppb.New(ra.clk.Now()),
+		Source:  source,
+	})
+	return err
+}
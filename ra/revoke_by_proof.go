@@ -0,0 +1,142 @@
+package ra
+
+import (
+	"context"
+	"crypto/x509"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/letsencrypt/boulder/core"
+	berrors "github.com/letsencrypt/boulder/errors"
+	rapb "github.com/letsencrypt/boulder/ra/proto"
+	"github.com/letsencrypt/boulder/revocation"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// RevokeCertificateByProof implements the self-service revocation path from
+// RFC 8555 §7.6: a subscriber may revoke a certificate without operator
+// intervention by presenting one of three proofs that they control it. The
+// WFE is responsible for verifying the request's JWS and resolving which
+// proof it represents; RevokeCertificateByProof re-checks that resolution
+// against the SA's own records before revoking, so a compromised or buggy
+// WFE can't bypass authorization. Once authorized, it shares the same
+// revocation and key-blocking handling as AdministrativelyRevokeCertificate.
+func (ra *RegistrationAuthorityImpl) RevokeCertificateByProof(ctx context.Context, req *rapb.RevokeCertificateByProofRequest) (*emptypb.Empty, error) {
+	if len(req.Cert) == 0 {
+		return nil, berrors.MalformedError("no certificate provided")
+	}
+	cert, err := x509.ParseCertificate(req.Cert)
+	if err != nil {
+		return nil, berrors.MalformedError("parsing certificate: %s", err)
+	}
+	serial := core.SerialToString(cert.SerialNumber)
+
+	meta, err := ra.SA.GetSerialMetadata(ctx, &sapb.Serial{Serial: serial})
+	if err != nil {
+		return nil, berrors.NotFoundError("certificate with serial %q not found: %s", serial, err)
+	}
+
+	err = ra.authorizeRevocationProof(ctx, req, cert, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ra.revokeBySerial(ctx, "subscriber-self-service", serial, revocation.Reason(req.Reason), false, false)
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// authorizeRevocationProof confirms that the proof the WFE claims to have
+// resolved actually holds, by re-deriving it from the SA's own records (or,
+// for a certificate-key proof, from the certificate itself) rather than
+// trusting the WFE's say-so.
+func (ra *RegistrationAuthorityImpl) authorizeRevocationProof(ctx context.Context, req *rapb.RevokeCertificateByProofRequest, cert *x509.Certificate, meta *sapb.SerialMetadata) error {
+	switch req.ProofType {
+	case rapb.ProofIssuingAccount:
+		return ra.checkIssuingAccount(req, meta)
+
+	case rapb.ProofIdentifierAuthorizations:
+		return ra.checkIdentifierAuthorizations(ctx, req, cert)
+
+	case rapb.ProofAccountKey:
+		// The WFE can tell a request was signed by an account key, but not
+		// whether that account is the issuing account (case a) or merely
+		// holds authorizations for every identifier (case b); try both.
+		if err := ra.checkIssuingAccount(req, meta); err == nil {
+			return nil
+		}
+		return ra.checkIdentifierAuthorizations(ctx, req, cert)
+
+	case rapb.ProofCertificateKey:
+		return ra.checkCertificateKey(req, cert)
+
+	default:
+		return berrors.UnauthorizedError("unrecognized revocation proof type %d", req.ProofType)
+	}
+}
+
+// checkIssuingAccount confirms req.AccountID is the account that requested
+// issuance of the certificate, per the SA's own record of it.
+func (ra *RegistrationAuthorityImpl) checkIssuingAccount(req *rapb.RevokeCertificateByProofRequest, meta *sapb.SerialMetadata) error {
+	if req.AccountID == 0 || req.AccountID != meta.RegistrationID {
+		return berrors.UnauthorizedError("account %d did not request issuance of this certificate", req.AccountID)
+	}
+	return nil
+}
+
+// checkIdentifierAuthorizations confirms req.AccountID currently holds a
+// valid authorization, per the SA, for every identifier on the certificate.
+func (ra *RegistrationAuthorityImpl) checkIdentifierAuthorizations(ctx context.Context, req *rapb.RevokeCertificateByProofRequest, cert *x509.Certificate) error {
+	if req.AccountID == 0 {
+		return berrors.UnauthorizedError("no account ID provided for authorization-based proof")
+	}
+	valid, err := ra.SA.GetValidAuthorizations2(ctx, &sapb.GetValidAuthorizationsRequest{
+		RegistrationID: req.AccountID,
+		DnsNames:       cert.DNSNames,
+		ValidUntil:     ra.clk.Now(),
+	})
+	if err != nil {
+		return berrors.InternalServerError("checking authorizations for account %d: %s", req.AccountID, err)
+	}
+	authorized := make(map[string]bool, len(valid.Authzs))
+	for _, authz := range valid.Authzs {
+		authorized[authz.Identifier] = true
+	}
+	for _, name := range cert.DNSNames {
+		if !authorized[name] {
+			return berrors.UnauthorizedError("account %d lacks a valid authorization for %q", req.AccountID, name)
+		}
+	}
+	return nil
+}
+
+// checkCertificateKey confirms the public key the WFE verified the JWS
+// against is actually the certificate's own public key, rather than
+// trusting the WFE to have already made that comparison. A client (or a
+// compromised WFE) can assert anything about its own JWS; the one thing it
+// can't forge is the certificate's real public key, so the RA must do this
+// comparison itself.
+func (ra *RegistrationAuthorityImpl) checkCertificateKey(req *rapb.RevokeCertificateByProofRequest, cert *x509.Certificate) error {
+	if len(req.CertificateKeyPKIXPublicKey) == 0 {
+		return berrors.UnauthorizedError("no certificate key provided for certificate-key proof")
+	}
+	signerKey, err := x509.ParsePKIXPublicKey(req.CertificateKeyPKIXPublicKey)
+	if err != nil {
+		return berrors.UnauthorizedError("parsing certificate key proof: %s", err)
+	}
+
+	signerDigest, err := core.KeyDigest(signerKey)
+	if err != nil {
+		return berrors.InternalServerError("hashing certificate key proof: %s", err)
+	}
+	certDigest, err := core.KeyDigest(cert.PublicKey)
+	if err != nil {
+		return berrors.InternalServerError("hashing certificate public key: %s", err)
+	}
+	if signerDigest != certDigest {
+		return berrors.UnauthorizedError("JWS was not signed by the certificate's own key")
+	}
+	return nil
+}
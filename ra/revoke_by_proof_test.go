@@ -0,0 +1,246 @@
+package ra
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/letsencrypt/boulder/core"
+	berrors "github.com/letsencrypt/boulder/errors"
+	blog "github.com/letsencrypt/boulder/log"
+	rapb "github.com/letsencrypt/boulder/ra/proto"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// mockSAForProofRevocation is a mock which only implements the SA methods
+// RevokeCertificateByProof needs: looking up which account requested a
+// serial, checking authorizations, and recording the revocation.
+type mockSAForProofRevocation struct {
+	sapb.StorageAuthorityClient
+
+	issuingRegID       int64
+	authorizedNames    map[string]bool
+	revocationRequests []*sapb.RevokeCertificateRequest
+	doomedToFail       bool
+}
+
+func (msa *mockSAForProofRevocation) GetSerialMetadata(_ context.Context, req *sapb.Serial, _ ...grpc.CallOption) (*sapb.SerialMetadata, error) {
+	return &sapb.SerialMetadata{Serial: req.Serial, RegistrationID: msa.issuingRegID}, nil
+}
+
+func (msa *mockSAForProofRevocation) GetValidAuthorizations2(_ context.Context, req *sapb.GetValidAuthorizationsRequest, _ ...grpc.CallOption) (*sapb.Authorizations, error) {
+	authz := &sapb.Authorizations{}
+	for _, name := range req.DnsNames {
+		if msa.authorizedNames[name] {
+			authz.Authzs = append(authz.Authzs, &sapb.Authorization{Identifier: name})
+		}
+	}
+	return authz, nil
+}
+
+func (msa *mockSAForProofRevocation) RevokeCertificate(_ context.Context, req *sapb.RevokeCertificateRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	if msa.doomedToFail {
+		return nil, errors.New("oops")
+	}
+	msa.revocationRequests = append(msa.revocationRequests, req)
+	return &emptypb.Empty{}, nil
+}
+
+func makeTestCert(t *testing.T, names ...string) (*x509.Certificate, []byte, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "generating test key")
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1337),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	test.AssertNotError(t, err, "creating test certificate")
+
+	cert, err := x509.ParseCertificate(der)
+	test.AssertNotError(t, err, "parsing test certificate")
+	return cert, der, key
+}
+
+func TestRevokeCertificateByProofIssuingAccount(t *testing.T) {
+	t.Parallel()
+	cert, der, _ := makeTestCert(t, "example.com")
+
+	sa := &mockSAForProofRevocation{issuingRegID: 1}
+	ra := &RegistrationAuthorityImpl{SA: sa, clk: clock.NewFake(), log: blog.NewMock()}
+
+	_, err := ra.RevokeCertificateByProof(context.Background(), &rapb.RevokeCertificateByProofRequest{
+		Cert:      der,
+		Reason:    0,
+		ProofType: rapb.ProofIssuingAccount,
+		AccountID: 1,
+	})
+	test.AssertNotError(t, err, "revoking with the issuing account's proof")
+	test.AssertEquals(t, len(sa.revocationRequests), 1)
+	test.AssertEquals(t, sa.revocationRequests[0].Serial, core.SerialToString(cert.SerialNumber))
+
+	// A different account should be rejected.
+	sa.revocationRequests = nil
+	_, err = ra.RevokeCertificateByProof(context.Background(), &rapb.RevokeCertificateByProofRequest{
+		Cert:      der,
+		ProofType: rapb.ProofIssuingAccount,
+		AccountID: 2,
+	})
+	test.AssertError(t, err, "revoking with the wrong account's proof")
+	test.AssertEquals(t, len(sa.revocationRequests), 0)
+}
+
+func TestRevokeCertificateByProofIdentifierAuthorizations(t *testing.T) {
+	t.Parallel()
+	_, der, _ := makeTestCert(t, "example.com")
+
+	sa := &mockSAForProofRevocation{
+		issuingRegID:    1,
+		authorizedNames: map[string]bool{"example.com": true},
+	}
+	ra := &RegistrationAuthorityImpl{SA: sa, clk: clock.NewFake(), log: blog.NewMock()}
+
+	_, err := ra.RevokeCertificateByProof(context.Background(), &rapb.RevokeCertificateByProofRequest{
+		Cert:      der,
+		ProofType: rapb.ProofIdentifierAuthorizations,
+		AccountID: 2,
+	})
+	test.AssertNotError(t, err, "revoking with a valid authorization proof")
+	test.AssertEquals(t, len(sa.revocationRequests), 1)
+
+	// An account without a valid authorization for every name should be
+	// rejected.
+	sa.revocationRequests = nil
+	sa.authorizedNames = nil
+	_, err = ra.RevokeCertificateByProof(context.Background(), &rapb.RevokeCertificateByProofRequest{
+		Cert:      der,
+		ProofType: rapb.ProofIdentifierAuthorizations,
+		AccountID: 3,
+	})
+	test.AssertError(t, err, "revoking without a valid authorization")
+	test.AssertEquals(t, len(sa.revocationRequests), 0)
+}
+
+func TestRevokeCertificateByProofCertificateKey(t *testing.T) {
+	t.Parallel()
+	_, der, key := makeTestCert(t, "example.com")
+
+	sa := &mockSAForProofRevocation{issuingRegID: 1}
+	ra := &RegistrationAuthorityImpl{SA: sa, clk: clock.NewFake(), log: blog.NewMock()}
+
+	certKeyPub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	test.AssertNotError(t, err, "marshalling test cert's public key")
+
+	// The WFE reports the key it verified the JWS against; the RA must
+	// independently confirm it's actually the certificate's own key.
+	_, err = ra.RevokeCertificateByProof(context.Background(), &rapb.RevokeCertificateByProofRequest{
+		Cert:                        der,
+		ProofType:                   rapb.ProofCertificateKey,
+		CertificateKeyPKIXPublicKey: certKeyPub,
+	})
+	test.AssertNotError(t, err, "revoking with a verified certificate-key proof")
+	test.AssertEquals(t, len(sa.revocationRequests), 1)
+
+	// A JWS verified against some other key must not be trusted, even
+	// though the WFE is the one asserting ProofCertificateKey: a
+	// compromised or buggy WFE must not be able to revoke an arbitrary
+	// certificate just by presenting a JWS signed by a key it controls.
+	sa.revocationRequests = nil
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "generating an unrelated test key")
+	otherKeyPub, err := x509.MarshalPKIXPublicKey(&otherKey.PublicKey)
+	test.AssertNotError(t, err, "marshalling unrelated test key")
+
+	_, err = ra.RevokeCertificateByProof(context.Background(), &rapb.RevokeCertificateByProofRequest{
+		Cert:                        der,
+		ProofType:                   rapb.ProofCertificateKey,
+		CertificateKeyPKIXPublicKey: otherKeyPub,
+	})
+	test.AssertError(t, err, "revoking with a proof key that doesn't match the certificate")
+	test.AssertEquals(t, len(sa.revocationRequests), 0)
+
+	// No key provided at all must also be rejected.
+	sa.revocationRequests = nil
+	_, err = ra.RevokeCertificateByProof(context.Background(), &rapb.RevokeCertificateByProofRequest{
+		Cert:      der,
+		ProofType: rapb.ProofCertificateKey,
+	})
+	test.AssertError(t, err, "revoking with no certificate-key proof")
+	test.AssertEquals(t, len(sa.revocationRequests), 0)
+}
+
+func TestRevokeCertificateByProofAccountKey(t *testing.T) {
+	t.Parallel()
+	_, der, _ := makeTestCert(t, "example.com")
+
+	// ProofAccountKey must succeed when the account is the issuing
+	// account, even though the WFE couldn't tell that from the JWS alone.
+	sa := &mockSAForProofRevocation{issuingRegID: 1}
+	ra := &RegistrationAuthorityImpl{SA: sa, clk: clock.NewFake(), log: blog.NewMock()}
+
+	_, err := ra.RevokeCertificateByProof(context.Background(), &rapb.RevokeCertificateByProofRequest{
+		Cert:      der,
+		ProofType: rapb.ProofAccountKey,
+		AccountID: 1,
+	})
+	test.AssertNotError(t, err, "revoking as the issuing account via ProofAccountKey")
+	test.AssertEquals(t, len(sa.revocationRequests), 1)
+
+	// ProofAccountKey must also succeed when the account merely holds
+	// authorizations for every identifier — RFC 8555 §7.6 case (b), which
+	// the WFE can't distinguish from case (a) up front.
+	sa = &mockSAForProofRevocation{issuingRegID: 1, authorizedNames: map[string]bool{"example.com": true}}
+	ra = &RegistrationAuthorityImpl{SA: sa, clk: clock.NewFake(), log: blog.NewMock()}
+
+	_, err = ra.RevokeCertificateByProof(context.Background(), &rapb.RevokeCertificateByProofRequest{
+		Cert:      der,
+		ProofType: rapb.ProofAccountKey,
+		AccountID: 2,
+	})
+	test.AssertNotError(t, err, "revoking as an authorized account via ProofAccountKey")
+	test.AssertEquals(t, len(sa.revocationRequests), 1)
+
+	// An account that is neither the issuer nor authorized must be
+	// rejected.
+	sa = &mockSAForProofRevocation{issuingRegID: 1}
+	ra = &RegistrationAuthorityImpl{SA: sa, clk: clock.NewFake(), log: blog.NewMock()}
+
+	_, err = ra.RevokeCertificateByProof(context.Background(), &rapb.RevokeCertificateByProofRequest{
+		Cert:      der,
+		ProofType: rapb.ProofAccountKey,
+		AccountID: 3,
+	})
+	test.AssertError(t, err, "revoking with an unrelated account via ProofAccountKey")
+	test.AssertEquals(t, len(sa.revocationRequests), 0)
+}
+
+func TestRevokeCertificateByProofUnrecognized(t *testing.T) {
+	t.Parallel()
+	_, der, _ := makeTestCert(t, "example.com")
+
+	sa := &mockSAForProofRevocation{issuingRegID: 1}
+	ra := &RegistrationAuthorityImpl{SA: sa, clk: clock.NewFake(), log: blog.NewMock()}
+
+	_, err := ra.RevokeCertificateByProof(context.Background(), &rapb.RevokeCertificateByProofRequest{
+		Cert:      der,
+		ProofType: 99,
+	})
+	test.AssertError(t, err, "revoking with an unrecognized proof type")
+	test.AssertDeepEquals(t, berrors.Is(err, berrors.Unauthorized), true)
+}
@@ -0,0 +1,150 @@
+// Package wfe2 is the ACME-facing web front end. This file adds the
+// revokeCert endpoint defined by RFC 8555 §7.6.
+package wfe2
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-jose/go-jose/v4"
+
+	"github.com/letsencrypt/boulder/core"
+	berrors "github.com/letsencrypt/boulder/errors"
+	rapb "github.com/letsencrypt/boulder/ra/proto"
+	"github.com/letsencrypt/boulder/revocation"
+	"github.com/letsencrypt/boulder/web"
+)
+
+// WebFrontEndImpl is the ACME protocol handler. Only the fields this file
+// touches are declared here; the rest of the WFE (account/order/challenge
+// handling, JWS helpers, rate limiting, etc.) lives in the sibling files
+// this checkout doesn't carry.
+type WebFrontEndImpl struct {
+	ra rapb.RegistrationAuthorityClient
+}
+
+// revokeCertByProofRequest is the body of a JWS-signed POST to the
+// revokeCert endpoint.
+type revokeCertByProofRequest struct {
+	Certificate string `json:"certificate"`
+	Reason      *int64 `json:"reason,omitempty"`
+}
+
+// RevokeCertificate implements the ACME revokeCert endpoint (RFC 8555
+// §7.6). It accepts a JWS signed by one of the three permitted keys — the
+// issuing account, an account authorized for every identifier on the
+// cert, or the certificate's own key pair — resolves whether the JWS was
+// signed by an account key or by an embedded key, and forwards the
+// request to the RA's
+// RevokeCertificateByProof, which re-validates that resolution itself
+// against its own records rather than trusting the WFE.
+func (wfe *WebFrontEndImpl) RevokeCertificate(ctx context.Context, logEvent *web.RequestEvent, response http.ResponseWriter, request *http.Request) {
+	payload, proofType, accountID, certKeyPub, err := wfe.resolveRevocationProof(ctx, request)
+	if err != nil {
+		web.SendError(response, logEvent, err)
+		return
+	}
+
+	var body revokeCertByProofRequest
+	err = json.Unmarshal(payload, &body)
+	if err != nil {
+		web.SendError(response, logEvent, err)
+		return
+	}
+
+	certDER, err := core.B64dec(body.Certificate)
+	if err != nil {
+		web.SendError(response, logEvent, err)
+		return
+	}
+
+	reason := revocation.Reason(0)
+	if body.Reason != nil {
+		reason = revocation.Reason(*body.Reason)
+	}
+
+	_, err = wfe.ra.RevokeCertificateByProof(ctx, &rapb.RevokeCertificateByProofRequest{
+		Cert:                        certDER,
+		Reason:                      int64(reason),
+		ProofType:                   proofType,
+		AccountID:                   accountID,
+		CertificateKeyPKIXPublicKey: certKeyPub,
+	})
+	if err != nil {
+		web.SendError(response, logEvent, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusOK)
+}
+
+// resolveRevocationProof validates the request's JWS and determines whether
+// it is an account-key proof (ProofAccountKey, which covers RFC 8555 §7.6
+// cases a and b without telling them apart) or a certificate-key proof
+// (ProofCertificateKey). It returns the JWS payload,
+// along with enough context for the RA to independently re-derive the same
+// conclusion: the account ID that signed the JWS (for an account-key
+// proof), or the embedded key that signed it, as a DER-encoded
+// SubjectPublicKeyInfo (for a certificate-key proof). Resolution here is
+// provisional — the RA re-checks it against the SA's records, or the
+// certificate's own public key, before acting on it.
+func (wfe *WebFrontEndImpl) resolveRevocationProof(ctx context.Context, request *http.Request) (payload []byte, proofType int64, accountID int64, certKeyPub []byte, err error) {
+	jws, err := web.ParseJWS(request)
+	if err != nil {
+		return nil, 0, 0, nil, err
+	}
+
+	header := jws.Signatures[0].Header
+	switch {
+	case header.KeyID != "":
+		// Signed by an account key: a JWS alone doesn't tell us whether
+		// that account is the issuing account (RFC 8555 §7.6 case a) or
+		// merely holds authorizations for every identifier (case b), so we
+		// report ProofAccountKey and let the RA try both.
+		accountID, err = web.AccountIDFromKeyID(header.KeyID)
+		if err != nil {
+			return nil, 0, 0, nil, err
+		}
+		payload, err = web.VerifyJWSWithAccount(ctx, jws, accountID)
+		if err != nil {
+			return nil, 0, 0, nil, err
+		}
+		return payload, rapb.ProofAccountKey, accountID, nil, nil
+
+	case header.JSONWebKey != nil:
+		// Signed by an embedded JWK: only valid if it matches the
+		// certificate's own public key, which the RA checks against
+		// certKeyPub once it has parsed the certificate.
+		payload, certKeyPub, err = verifyJWSWithEmbeddedKey(jws, header.JSONWebKey)
+		if err != nil {
+			return nil, 0, 0, nil, err
+		}
+		return payload, rapb.ProofCertificateKey, 0, certKeyPub, nil
+
+	default:
+		return nil, 0, 0, nil, errNoSignerKey
+	}
+}
+
+// errNoSignerKey is returned when a revocation JWS carries neither a "kid"
+// nor an embedded "jwk".
+var errNoSignerKey = berrors.MalformedError("POST JWS not signed by an account key ID or an embedded JWK")
+
+// verifyJWSWithEmbeddedKey checks that jws was signed by the given
+// embedded key, and returns that key as a DER-encoded SubjectPublicKeyInfo
+// alongside the verified payload. It does not compare the key to the
+// certificate being revoked — the RA does that itself, since it's the one
+// place that can be trusted to have actually parsed the certificate.
+func verifyJWSWithEmbeddedKey(jws *jose.JSONWebSignature, key *jose.JSONWebKey) (payload []byte, pkixPublicKey []byte, err error) {
+	payload, err = jws.Verify(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	pkixPublicKey, err = x509.MarshalPKIXPublicKey(key.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return payload, pkixPublicKey, nil
+}